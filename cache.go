@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// cacheDir is where per-file and per-directory Stats are persisted
+// between runs, overridable with -cache. An empty value disables the
+// cache entirely.
+var cacheDir = defaultCacheDir()
+
+func defaultCacheDir() string {
+	if d := os.Getenv("XDG_CACHE_HOME"); d != "" {
+		return filepath.Join(d, "sloc")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cache", "sloc")
+}
+
+// modeTag distinguishes cache entries produced with -ast from those
+// produced by the byte scanner, since the two can disagree on Stats
+// for the same bytes.
+func modeTag() string {
+	if astMode {
+		return "ast"
+	}
+	return "scan"
+}
+
+// langStats is a per-language Stats snapshot, either the running
+// global totals (info) or the contribution of a single file/directory
+// that gets folded into it.
+type langStats map[string]*Stats
+
+func addStats(dst, src *Stats) {
+	dst.FileCount += src.FileCount
+	dst.TotalLines += src.TotalLines
+	dst.CodeLines += src.CodeLines
+	dst.BlankLines += src.BlankLines
+	dst.CommentLines += src.CommentLines
+	dst.DirectiveLines += src.DirectiveLines
+}
+
+// mergeInto folds ls into the global info map.
+func mergeInto(ls langStats) {
+	for name, s := range ls {
+		i, ok := info[name]
+		if !ok {
+			i = &Stats{}
+			info[name] = i
+		}
+		addStats(i, s)
+	}
+}
+
+// dirHash fingerprints every regular file under root in fsys using the
+// same scheme as golang.org/x/mod/sumdb/dirhash's HashDir/Hash1: sort
+// the file paths, hash each file's content, then hash the concatenated
+// "<sha256-hex>  <path>\n" lines in that path order. Stat metadata
+// (size, mtime) is not enough - two files of identical size with a
+// manually-preserved mtime can still differ in content, and a stale
+// cache hit would then return last run's Stats instead of recounting.
+// Hashing content means a directory-level hit costs one read per file
+// rather than one stat, but that read is exactly what guarantees the
+// cached Stats are still correct.
+func dirHash(fsys fs.FS, root string) (string, error) {
+	paths, err := (Scanner{FS: fsys, Root: root}).Walk()
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, p := range paths {
+		c, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s  %s\n", fileHash(c), p)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileHash(c []byte) string {
+	sum := sha256.Sum256(c)
+	return hex.EncodeToString(sum[:])
+}
+
+type cacheEntry struct {
+	Languages langStats `json:"languages"`
+}
+
+func cachePath(key string) string {
+	return filepath.Join(cacheDir, base64.RawURLEncoding.EncodeToString([]byte(key))+".json")
+}
+
+func loadCache(key string) (langStats, bool) {
+	if cacheDir == "" {
+		return nil, false
+	}
+	b, err := os.ReadFile(cachePath(key))
+	if err != nil {
+		return nil, false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, false
+	}
+	return e.Languages, true
+}
+
+// storeCache writes ls to key's cache file atomically: the worker pool
+// in workFiles has many goroutines racing to cache the same content
+// hash (duplicate-content files are common), and two unsynchronized
+// os.WriteFile calls to the same path can interleave and leave corrupt
+// JSON on disk. Writing to a temp file in cacheDir and renaming over
+// the destination means every reader always sees either the old or
+// the new contents, never a mix of both.
+func storeCache(key string, ls langStats) {
+	if cacheDir == "" {
+		return
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return
+	}
+	b, err := json.Marshal(cacheEntry{Languages: ls})
+	if err != nil {
+		return
+	}
+	dst := cachePath(key)
+	tmp, err := os.CreateTemp(cacheDir, "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// outputFormat selects printInfo's replacement via -format: "text"
+// (the tabwriter table, default), "json", or "csv".
+var outputFormat string
+
+// diffPath, set via -diff, names a previous JSON report to compare the
+// current run against instead of printing a standalone report.
+var diffPath string
+
+// langRow is one language's totals, shaped to match the -format
+// json/csv output schema.
+type langRow struct {
+	Name      string `json:"name"`
+	Files     int    `json:"files"`
+	Code      int    `json:"code"`
+	Comment   int    `json:"comment"`
+	Directive int    `json:"directive"`
+	Blank     int    `json:"blank"`
+	Total     int    `json:"total"`
+}
+
+type report struct {
+	Languages []langRow `json:"languages"`
+	Totals    langRow   `json:"totals"`
+}
+
+func sortedNames() []string {
+	names := make([]string, 0, len(info))
+	for n := range info {
+		names = append(names, n)
+	}
+	if sortByCode {
+		sort.Slice(names, func(a, b int) bool {
+			return info[names[a]].CodeLines > info[names[b]].CodeLines
+		})
+	} else {
+		sort.Strings(names)
+	}
+	return names
+}
+
+func buildReport() report {
+	var r report
+	r.Totals.Name = "TOTAL"
+	for _, n := range sortedNames() {
+		i := info[n]
+		row := langRow{n, i.FileCount, i.CodeLines, i.CommentLines, i.DirectiveLines, i.BlankLines, i.TotalLines}
+		r.Languages = append(r.Languages, row)
+		r.Totals.Files += row.Files
+		r.Totals.Code += row.Code
+		r.Totals.Comment += row.Comment
+		r.Totals.Directive += row.Directive
+		r.Totals.Blank += row.Blank
+		r.Totals.Total += row.Total
+	}
+	return r
+}
+
+func printJSON(r report) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  ! %v\n", err)
+		return
+	}
+	fmt.Println(string(b))
+}
+
+func printCSV(r report) {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"language", "files", "code", "comment", "directive", "blank", "total"})
+	for _, row := range r.Languages {
+		writeCSVRow(w, row)
+	}
+	writeCSVRow(w, r.Totals)
+	w.Flush()
+}
+
+func writeCSVRow(w *csv.Writer, row langRow) {
+	w.Write([]string{
+		row.Name,
+		fmt.Sprint(row.Files),
+		fmt.Sprint(row.Code),
+		fmt.Sprint(row.Comment),
+		fmt.Sprint(row.Directive),
+		fmt.Sprint(row.Blank),
+		fmt.Sprint(row.Total),
+	})
+}
+
+// loadReport reads a report previously written with -format json, for
+// use with -diff.
+func loadReport(path string) (report, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return report{}, err
+	}
+	var r report
+	if err := json.Unmarshal(b, &r); err != nil {
+		return report{}, err
+	}
+	return r, nil
+}
+
+// printDiff compares old against the current run and prints a
+// per-language delta table, so sloc can be used as a PR-comment bot in
+// CI without shelling out to jq.
+func printDiff(oldPath string, cur report) {
+	old, err := loadReport(oldPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  ! %s: %v\n", oldPath, err)
+		return
+	}
+
+	oldByName := map[string]langRow{}
+	for _, row := range old.Languages {
+		oldByName[row.Name] = row
+	}
+	names := map[string]bool{}
+	for _, row := range cur.Languages {
+		names[row.Name] = true
+	}
+	for n := range oldByName {
+		names[n] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	curByName := map[string]langRow{}
+	for _, row := range cur.Languages {
+		curByName[row.Name] = row
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Language\tFiles\tCode\tComment\tDirective\tBlank\tTotal\t")
+	for _, n := range sorted {
+		o, c := oldByName[n], curByName[n]
+		fmt.Fprintf(w, "%s\t%+d\t%+d\t%+d\t%+d\t%+d\t%+d\t\n",
+			n,
+			c.Files-o.Files,
+			c.Code-o.Code,
+			c.Comment-o.Comment,
+			c.Directive-o.Directive,
+			c.Blank-o.Blank,
+			c.Total-o.Total)
+	}
+	w.Flush()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout redirects os.Stdout for the duration of fn and returns
+// everything written to it, for the print* functions below that write
+// straight to os.Stdout rather than taking an io.Writer.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	os.Stdout = w
+	fn()
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		t.Fatalf("read captured stdout: %v", err)
+	}
+	return buf.String()
+}
+
+func sampleReport() report {
+	return report{
+		Languages: []langRow{
+			{Name: "Go", Files: 2, Code: 10, Comment: 3, Directive: 1, Blank: 2, Total: 16},
+			{Name: "Python", Files: 1, Code: 5, Comment: 0, Directive: 0, Blank: 1, Total: 6},
+		},
+		Totals: langRow{Name: "TOTAL", Files: 3, Code: 15, Comment: 3, Directive: 1, Blank: 3, Total: 22},
+	}
+}
+
+// TestPrintJSONSchema locks down the -format json field names: other
+// programs parse this output (that's the point of -format per its
+// request), so a rename here is a breaking change, not a refactor.
+func TestPrintJSONSchema(t *testing.T) {
+	out := captureStdout(t, func() { printJSON(sampleReport()) })
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("printJSON output doesn't parse as JSON: %v\n%s", err, out)
+	}
+
+	langs, ok := decoded["languages"].([]interface{})
+	if !ok || len(langs) != 2 {
+		t.Fatalf("languages = %#v, want a 2-element array", decoded["languages"])
+	}
+	first := langs[0].(map[string]interface{})
+	for _, field := range []string{"name", "files", "code", "comment", "directive", "blank", "total"} {
+		if _, ok := first[field]; !ok {
+			t.Errorf("language row missing %q field: %#v", field, first)
+		}
+	}
+	if first["name"] != "Go" || first["code"].(float64) != 10 {
+		t.Errorf("first language row = %#v, want name=Go code=10", first)
+	}
+
+	totals, ok := decoded["totals"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("totals = %#v, want an object", decoded["totals"])
+	}
+	if totals["name"] != "TOTAL" || totals["total"].(float64) != 22 {
+		t.Errorf("totals = %#v, want name=TOTAL total=22", totals)
+	}
+}
+
+// TestPrintCSVSchema locks down the -format csv header and row order.
+func TestPrintCSVSchema(t *testing.T) {
+	out := captureStdout(t, func() { printCSV(sampleReport()) })
+
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("printCSV output doesn't parse as CSV: %v\n%s", err, out)
+	}
+	if len(rows) != 4 { // header + 2 languages + totals
+		t.Fatalf("got %d rows, want 4:\n%v", len(rows), rows)
+	}
+
+	wantHeader := []string{"language", "files", "code", "comment", "directive", "blank", "total"}
+	for i, col := range wantHeader {
+		if rows[0][i] != col {
+			t.Errorf("header[%d] = %q, want %q", i, rows[0][i], col)
+		}
+	}
+
+	wantGo := []string{"Go", "2", "10", "3", "1", "2", "16"}
+	if got := rows[1]; !equalRows(got, wantGo) {
+		t.Errorf("Go row = %v, want %v", got, wantGo)
+	}
+
+	wantTotal := []string{"TOTAL", "3", "15", "3", "1", "3", "22"}
+	if got := rows[3]; !equalRows(got, wantTotal) {
+		t.Errorf("totals row = %v, want %v", got, wantTotal)
+	}
+}
+
+func equalRows(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestPrintDiffDelta locks down the sign and magnitude of -diff's
+// per-language deltas, including a language that only exists on one
+// side of the comparison.
+func TestPrintDiffDelta(t *testing.T) {
+	old := report{
+		Languages: []langRow{
+			{Name: "Go", Files: 2, Code: 10, Comment: 3, Directive: 1, Blank: 2, Total: 16},
+			{Name: "Haskell", Files: 1, Code: 4, Comment: 0, Directive: 0, Blank: 0, Total: 4},
+		},
+	}
+	cur := report{
+		Languages: []langRow{
+			{Name: "Go", Files: 2, Code: 6, Comment: 3, Directive: 1, Blank: 2, Total: 12},
+			{Name: "Python", Files: 1, Code: 5, Comment: 0, Directive: 0, Blank: 1, Total: 6},
+		},
+	}
+
+	oldPath := filepath.Join(t.TempDir(), "old.json")
+	b, err := json.Marshal(old)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if err := os.WriteFile(oldPath, b, 0o644); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+
+	out := captureStdout(t, func() { printDiff(oldPath, cur) })
+
+	if !strings.Contains(out, "Go") || !strings.Contains(out, "-4") {
+		t.Errorf("diff output missing Go's -4 code delta:\n%s", out)
+	}
+	if !strings.Contains(out, "Haskell") || !strings.Contains(out, "-4") {
+		t.Errorf("diff output missing Haskell dropping out entirely (-4 code):\n%s", out)
+	}
+	if !strings.Contains(out, "Python") || !strings.Contains(out, "+5") {
+		t.Errorf("diff output missing Python appearing new (+5 code):\n%s", out)
+	}
+}
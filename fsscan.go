@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing/fstest"
+)
+
+// Scanner walks an fs.FS rooted at Root and returns the regular files
+// under it, skipping dotfiles and dot-directories. fs.WalkDir never
+// follows symlinks, so a symlink to a directory is skipped rather than
+// traversed into (the pre-io/fs recursive walker, built on os.Stat,
+// used to follow it); it is at least no longer mistaken for a regular
+// file and pushed onto the result list, where fs.ReadFile would fail
+// on it.
+type Scanner struct {
+	FS   fs.FS
+	Root string
+}
+
+func (s Scanner) Walk() ([]string, error) {
+	var out []string
+	err := fs.WalkDir(s.FS, s.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  ! %s\n", p)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 && s.isDir(p) {
+			fmt.Fprintf(os.Stderr, "  ! %s: directory symlink skipped, not followed\n", p)
+			return nil // fs.WalkDir won't follow it, so its contents are skipped, not counted
+		}
+		if !d.IsDir() {
+			out = append(out, p)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// isDir reports whether p, followed through symlinks, is a directory.
+// fs.WalkDir never follows symlinks itself (DirEntry.IsDir reflects the
+// on-disk type, not the target), but the os.Stat-based walk this
+// Scanner replaced did, so without this a symlink to a directory would
+// be pushed onto out and fail fs.ReadFile as if it were a regular file.
+func (s Scanner) isDir(p string) bool {
+	fi, err := fs.Stat(s.FS, p)
+	return err == nil && fi.IsDir()
+}
+
+// tarFS reads an entire tar stream (optionally gzip-compressed) into
+// memory and exposes it as an fs.FS via testing/fstest.MapFS, so it can
+// be walked and read with the same Scanner/fs.ReadFile path as a local
+// directory or a zip archive.
+func tarFS(r io.Reader, gzipped bool) (fs.FS, error) {
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	mfs := fstest.MapFS{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		mfs[path.Clean(hdr.Name)] = &fstest.MapFile{Data: data, Mode: hdr.FileInfo().Mode()}
+	}
+	return mfs, nil
+}
+
+// openArchive opens n as a .zip, .tar, or .tar.gz/.tgz file and returns
+// it as an fs.FS rooted at ".", so callers can count lines inside a
+// release artifact without extracting it first. It reports whether n
+// was recognized as an archive; if not, the caller should fall back to
+// treating n as a local path. tarFS reads the whole stream into memory
+// up front and needs nothing held open afterwards, but a zip.Reader
+// seeks into its backing file on demand, so the returned io.Closer is
+// non-nil for a zip and the caller must Close it once done with fsys.
+func openArchive(n string) (fsys fs.FS, closer io.Closer, ok bool) {
+	switch {
+	case strings.HasSuffix(n, ".zip"):
+		zr, err := zip.OpenReader(n)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  ! %s\n", n)
+			return nil, nil, true
+		}
+		return &zr.Reader, zr, true
+
+	case strings.HasSuffix(n, ".tar"), strings.HasSuffix(n, ".tar.gz"), strings.HasSuffix(n, ".tgz"):
+		f, err := os.Open(n)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  ! %s\n", n)
+			return nil, nil, true
+		}
+		defer f.Close()
+		tfs, err := tarFS(f, strings.HasSuffix(n, ".gz") || strings.HasSuffix(n, ".tgz"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  ! %s\n", n)
+			return nil, nil, true
+		}
+		return tfs, nil, true
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// astMode enables the go/parser-based accounting path for .go files
+// (see -ast in main). It is off by default because it costs more than
+// the byte scanner and still falls back to it on any parse error.
+var astMode bool
+
+// updateGo classifies a Go source file by tokenizing it with go/scanner
+// rather than walking ast.Inspect's statement nodes: a *ast.BlockStmt's
+// line range spans its braces, so the original ast.Inspect-based
+// version counted every blank line inside a multi-line block as code.
+// Scanning tokens directly avoids that, while still using f.Comments
+// (via go/parser) to classify comment lines and to tally //go:build /
+// // +build lines separately in s.DirectiveLines. It reports whether
+// parsing succeeded; on failure (e.g. a package-clause-less fragment)
+// the caller should fall back to Language.Update so partial files
+// still get counted.
+func updateGo(c []byte, s *Stats) bool {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", c, parser.ParseComments)
+	if err != nil {
+		return false
+	}
+	s.FileCount++
+
+	total := strings.Count(string(c), "\n")
+	if len(c) > 0 && c[len(c)-1] != '\n' {
+		total++
+	}
+	s.TotalLines += total
+
+	tfile := fset.File(f.Pos())
+	code := make(map[int]bool, total)
+
+	var sc scanner.Scanner
+	sc.Init(tfile, c, nil, scanner.ScanComments)
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.COMMENT || tok == token.SEMICOLON {
+			// Comment lines are derived from f.Comments below.
+			// Automatically-inserted semicolons report lit == "\n",
+			// which would otherwise bleed their line count onto the
+			// following (possibly blank) line.
+			continue
+		}
+		start := fset.Position(pos).Line
+		for ln := start; ln <= start+strings.Count(lit, "\n"); ln++ {
+			code[ln] = true
+		}
+	}
+
+	comment := make(map[int]bool)
+	directive := make(map[int]bool)
+	for _, cg := range f.Comments {
+		for _, cmt := range cg.List {
+			start := fset.Position(cmt.Pos()).Line
+			end := fset.Position(cmt.End()).Line
+			isDirective := strings.HasPrefix(cmt.Text, "//go:build") || strings.HasPrefix(cmt.Text, "// +build")
+			for ln := start; ln <= end; ln++ {
+				if isDirective {
+					directive[ln] = true
+				} else {
+					comment[ln] = true
+				}
+			}
+		}
+	}
+
+	// The switch below mirrors Language.Update's line classification
+	// (including its quirk of also counting comment-only and blank
+	// lines as code) so a report mixing Go's -ast rows with the byte
+	// scanner's rows for other languages stays comparable column for
+	// column.
+	lines := strings.SplitN(string(c), "\n", total+1)
+	for i := 0; i < total; i++ {
+		ln := i + 1
+		blank := blankR.MatchString(lines[i])
+		switch {
+		case directive[ln]:
+			s.DirectiveLines++
+		case code[ln] && comment[ln]:
+			// A trailing comment, e.g. "foo() // note": counts
+			// towards both tallies rather than letting code win.
+			s.CodeLines++
+			s.CommentLines++
+		case code[ln]:
+			s.CodeLines++
+		case comment[ln]:
+			if !blank {
+				s.CodeLines++
+			}
+			s.CommentLines++
+		case blank:
+			s.BlankLines++
+			s.CodeLines++
+		default:
+			s.CodeLines++
+		}
+	}
+	return true
+}
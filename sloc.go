@@ -3,7 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
 	"os"
 	"path"
 	"regexp"
@@ -27,67 +27,119 @@ type Language struct {
 	Namer
 	Matcher
 	Commenter
+	Stringer
 }
 
 var (
 	blankR = regexp.MustCompile(`^[ \t]*$`)
 )
 
+// scanState is where the tokenizer is inside a line: plain code, one of
+// the two comment forms, or inside a string literal (which shields
+// whatever it contains from being read as a comment).
+type scanState int
+
+const (
+	inCode scanState = iota
+	inLineComment
+	inBlockComment
+	inString
+)
+
+// Update walks c as a small state machine instead of matching comment
+// delimiters byte-by-byte against the raw text, so a comment marker
+// inside a string literal (`"// not a comment"`, a back-tick raw
+// string containing `/*`) is counted as code, not comment.
 func (l Language) Update(c []byte, s *Stats) {
 	s.FileCount++
-	// line pointers
+
+	lc := l.LineComment
+	sc := l.StartComment
+	ec := l.EndComment
+
+	state := inCode
+	depth := 0
+	var str StringDelim
 	lStart := 0
 
-	inComment := 0 // this is an int for nesting
-	inLComment := false
-	lc := []byte(l.LineComment)
-	sc := []byte(l.StartComment)
-	ec := []byte(l.EndComment)
-	lp, sp, ep := 0, 0, 0
-
-	for i, b := range c {
-		if b == lc[lp] && !(inComment > 0) {
-			lp++
-			if lp == len(lc) {
-				inLComment = true
-				lp = 0
-			}
-		} else { lp = 0 }
-		if b == sc[sp] && !inLComment {
-			sp++
-			if sp == len(sc) {
-				inComment++
-				if inComment > 1 && !l.Nesting {
-					inComment = 1
-				}
-				sp = 0
-			}
-		} else { sp = 0 }
-		if b == ec[ep] && !inLComment && inComment > 0 {
-			ep++
-			if ep == len(ec) {
-				inComment--
-				ep = 0
-			}
-		} else { ep = 0 }
+	for i := 0; i < len(c); {
+		b := c[i]
 
-		// Note that lines with both code and comment count towards
-		// each, but are not counted twice in the total.
-		if b == byte('\n') {
+		if b == '\n' {
 			s.TotalLines++
-			if blankR.Match(c[lStart:i]) {
+			blank := blankR.Match(c[lStart:i])
+			if blank {
 				s.BlankLines++
 			}
-			if inComment > 0 || inLComment {
-				if !blankR.Match(c[lStart:i]) {
+			if state == inLineComment || state == inBlockComment {
+				if !blank {
 					s.CodeLines++
 				}
-				inLComment = false
 				s.CommentLines++
-			} else { s.CodeLines++ }
+				if state == inLineComment {
+					state = inCode
+				}
+			} else {
+				s.CodeLines++
+			}
 			lStart = i + 1
+			i++
 			continue
 		}
+
+		switch state {
+		case inLineComment:
+			i++
+
+		case inBlockComment:
+			if hasPrefixAt(c, i, ec) {
+				depth--
+				i += len(ec)
+				if depth <= 0 {
+					state, depth = inCode, 0
+				}
+				continue
+			}
+			if l.Nesting && hasPrefixAt(c, i, sc) {
+				depth++
+				i += len(sc)
+				continue
+			}
+			i++
+
+		case inString:
+			if str.Escape != "" && hasPrefixAt(c, i, str.Escape) {
+				i += len(str.Escape)
+				if i < len(c) && c[i] != '\n' {
+					i++
+				}
+				continue
+			}
+			if hasPrefixAt(c, i, str.End) {
+				i += len(str.End)
+				state = inCode
+				continue
+			}
+			i++
+
+		default: // inCode
+			if hasPrefixAt(c, i, lc) {
+				state = inLineComment
+				i += len(lc)
+				continue
+			}
+			if hasPrefixAt(c, i, sc) {
+				state, depth = inBlockComment, 1
+				i += len(sc)
+				continue
+			}
+			if d, ok := matchStart(l.Strings, c, i); ok {
+				state, str = inString, d
+				i += len(d.Start)
+				continue
+			}
+			i++
+		}
 	}
 }
 
@@ -122,28 +174,32 @@ func mName(names ...string) Matcher {
 }
 
 type Stats struct {
-	FileCount    int
-	TotalLines   int
-	CodeLines    int
-	BlankLines   int
-	CommentLines int
+	FileCount      int
+	TotalLines     int
+	CodeLines      int
+	BlankLines     int
+	CommentLines   int
+	DirectiveLines int
 }
 
 var info = map[string]*Stats{}
 
 var languages = []Language{
-	Language{"C", mExt(".c", ".h"), cComments},
-	Language{"C++", mExt(".cc", ".cpp", ".cxx", ".hh", ".hpp", ".hxx"), cComments},
-	Language{"Go", mExt(".go"), cComments},
-	Language{"Haskell", mExt(".hs", ".lhs"), noComments},
-	Language{"Perl", mExt(".pl", ".pm"), shComments},
-	Language{"Python", mExt(".py"), noComments},
-	Language{"Lisp", mExt(".lsp"), noComments},
-	Language{"Make", mName("makefile", "Makefile", "MAKEFILE"), shComments},
-	Language{"HTML", mExt(".htm", ".html", ".xhtml"), noComments},
+	Language{"C", mExt(".c", ".h"), cComments, cStrings},
+	Language{"C++", mExt(".cc", ".cpp", ".cxx", ".hh", ".hpp", ".hxx"), cComments, cStrings},
+	Language{"Go", mExt(".go"), cComments, cStrings},
+	Language{"Haskell", mExt(".hs", ".lhs"), noComments, noStrings},
+	Language{"Perl", mExt(".pl", ".pm"), shComments, shStrings},
+	Language{"Python", mExt(".py"), noComments, pyStrings},
+	Language{"Lisp", mExt(".lsp"), noComments, noStrings},
+	Language{"Make", mName("makefile", "Makefile", "MAKEFILE"), shComments, shStrings},
+	Language{"HTML", mExt(".htm", ".html", ".xhtml"), noComments, noStrings},
 }
 
-func handleFile(fname, content string) {
+// handleFile classifies fname and folds its contribution into out. The
+// per-file result is cached under a hash of its content, keyed to the
+// current -ast mode, so unchanged files are skipped on the next run.
+func handleFile(fsys fs.FS, fname string, out langStats) {
 	var l Language
 	ok := false
 	for _, lang := range languages {
@@ -156,85 +212,159 @@ func handleFile(fname, content string) {
 	if !ok {
 		return // ignore this file
 	}
-	i, ok := info[l.Name()]
-	if !ok {
-		i = &Stats{}
-		info[l.Name()] = i
-	}
-	c, err := ioutil.ReadFile(fname)
+
+	c, err := fs.ReadFile(fsys, fname)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "  ! %s\n", fname)
 		return
 	}
-	l.Update(c, i)
+
+	key := "file:" + l.Name() + ":" + modeTag() + ":" + fileHash(c)
+	if cached, ok := loadCache(key); ok {
+		for name, s := range cached {
+			i, ok := out[name]
+			if !ok {
+				i = &Stats{}
+				out[name] = i
+			}
+			addStats(i, s)
+		}
+		return
+	}
+
+	s := &Stats{}
+	if !(astMode && l.Name() == "Go" && updateGo(c, s)) {
+		l.Update(c, s)
+	}
+	storeCache(key, langStats{l.Name(): s})
+
+	i, ok := out[l.Name()]
+	if !ok {
+		i = &Stats{}
+		out[l.Name()] = i
+	}
+	addStats(i, s)
 }
 
+// sortByCode switches printInfo's row order from alphabetical (the
+// default) to descending code-line count, via -sort.
+var sortByCode bool
+
 func printInfo() {
 	w := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', tabwriter.AlignRight)
-	fmt.Fprintln(w, "Language\tFiles\tCode\tComment\tBlank\tTotal\t")
-	for n, i := range info {
+	fmt.Fprintln(w, "Language\tFiles\tCode\tComment\tDirective\tBlank\tTotal\t")
+	for _, n := range sortedNames() {
+		i := info[n]
 		fmt.Fprintf(
 			w,
-			"%s\t%d\t%d\t%d\t%d\t%d\t\n",
+			"%s\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
 			n,
 			i.FileCount,
 			i.CodeLines,
 			i.CommentLines,
+			i.DirectiveLines,
 			i.BlankLines,
 			i.TotalLines)
 	}
 	w.Flush()
 }
 
-var files []string
+// processDir walks fsys, folding every file it finds into a langStats
+// map. The cache key is a content hash of every file under the tree
+// (see dirHash); on a hit the per-file parsing/counting is skipped and
+// the cached totals are reused directly, though computing the key
+// itself still reads every file, same as the per-file cache does. With
+// -cache "" there's no cache to hit, so dirHash is skipped entirely -
+// otherwise every run would read every file twice, once pointlessly
+// for the key and once in workFiles.
+func processDir(fsys fs.FS) langStats {
+	key := ""
+	if cacheDir != "" {
+		if h, err := dirHash(fsys, "."); err == nil {
+			key = "dir:" + modeTag() + ":" + h
+			if cached, ok := loadCache(key); ok {
+				return cached
+			}
+		}
+	}
 
-func add(n string) {
-	fi, err := os.Stat(n)
+	paths, err := (Scanner{FS: fsys, Root: "."}).Walk()
 	if err != nil {
-		goto invalid
+		fmt.Fprintf(os.Stderr, "  ! .\n")
+		return langStats{}
 	}
-	if fi.IsDir() {
-		fs, err := ioutil.ReadDir(n)
-		if err != nil {
-			goto invalid
+	out := workFiles(fsys, paths)
+	if key != "" {
+		storeCache(key, out)
+	}
+	return out
+}
+
+// processArg resolves n to an fs.FS - a local file, a local directory,
+// or an archive - and folds its Stats into the global info map.
+func processArg(n string) {
+	if fsys, closer, ok := openArchive(n); ok {
+		if fsys != nil {
+			mergeInto(processDir(fsys))
 		}
-		for _, f := range fs {
-			if f.Name()[0] != '.' {
-				add(path.Join(n, f.Name()))
-			}
+		if closer != nil {
+			closer.Close()
 		}
 		return
 	}
+
+	fi, err := os.Stat(n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  ! %s\n", n)
+		return
+	}
+	if fi.IsDir() {
+		mergeInto(processDir(os.DirFS(n)))
+		return
+	}
 	if fi.Mode()&os.ModeType == 0 {
-		files = append(files, n)
+		out := langStats{}
+		handleFile(os.DirFS(path.Dir(n)), path.Base(n), out)
+		mergeInto(out)
 		return
 	}
 
-	println(fi.Mode())
-
-invalid:
 	fmt.Fprintf(os.Stderr, "  ! %s\n", n)
 }
 
 func main() {
+	flag.BoolVar(&astMode, "ast", false, "use go/parser for exact .go accounting instead of the byte scanner")
+	flag.StringVar(&cacheDir, "cache", cacheDir, "directory to cache per-file/per-directory Stats in (empty disables the cache)")
+	flag.IntVar(&numWorkers, "j", numWorkers, "number of files to process concurrently per directory/archive")
+	flag.BoolVar(&sortByCode, "sort", false, "sort output by code-line count instead of alphabetically")
+	flag.StringVar(&outputFormat, "format", "text", "output format: text, json, or csv")
+	flag.StringVar(&diffPath, "diff", "", "compare against a previous -format json report instead of printing totals")
 	flag.Parse()
 
+	switch outputFormat {
+	case "text", "json", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "sloc: unknown -format %q (want text, json, or csv)\n", outputFormat)
+		os.Exit(2)
+	}
+
 	args := flag.Args()
 	if len(args) == 0 {
 		args = append(args, `.`)
 	}
 
 	for _, n := range args {
-		add(n)
+		processArg(n)
 	}
 
-	for _, f := range files {
-		c, err := ioutil.ReadFile(f)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "  ! %s\n", f)
-		}
-		cs := string(c)
-		handleFile(f, cs)
+	switch {
+	case diffPath != "":
+		printDiff(diffPath, buildReport())
+	case outputFormat == "json":
+		printJSON(buildReport())
+	case outputFormat == "csv":
+		printCSV(buildReport())
+	default:
+		printInfo()
 	}
-	printInfo()
 }
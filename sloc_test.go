@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func goLang() Language {
+	for _, l := range languages {
+		if l.Name() == "Go" {
+			return l
+		}
+	}
+	panic("Go language not registered")
+}
+
+func TestUpdateCommentMarkerInsideString(t *testing.T) {
+	l := goLang()
+	var s Stats
+	l.Update([]byte(`x := "// not a comment"`+"\n"), &s)
+	if s.CommentLines != 0 {
+		t.Fatalf("CommentLines = %d, want 0", s.CommentLines)
+	}
+	if s.CodeLines != 1 {
+		t.Fatalf("CodeLines = %d, want 1", s.CodeLines)
+	}
+}
+
+func TestUpdateBlockMarkerInsideRawString(t *testing.T) {
+	l := goLang()
+	var s Stats
+	l.Update([]byte("x := `/* not a comment */`\n"), &s)
+	if s.CommentLines != 0 {
+		t.Fatalf("CommentLines = %d, want 0", s.CommentLines)
+	}
+	if s.CodeLines != 1 {
+		t.Fatalf("CodeLines = %d, want 1", s.CodeLines)
+	}
+}
+
+func TestUpdateStringSpanningMultipleLines(t *testing.T) {
+	l := goLang()
+	var s Stats
+	l.Update([]byte("x := `line one\nline two // still a string`\n"), &s)
+	if s.CommentLines != 0 {
+		t.Fatalf("CommentLines = %d, want 0", s.CommentLines)
+	}
+	if s.CodeLines != 2 {
+		t.Fatalf("CodeLines = %d, want 2", s.CodeLines)
+	}
+}
+
+func TestUpdateEscapedQuoteDoesNotCloseString(t *testing.T) {
+	l := goLang()
+	var s Stats
+	l.Update([]byte(`x := "a \" // still a string"`+"\n"), &s)
+	if s.CommentLines != 0 {
+		t.Fatalf("CommentLines = %d, want 0", s.CommentLines)
+	}
+}
+
+func TestUpdateGoTrailingComment(t *testing.T) {
+	var s Stats
+	if !updateGo([]byte("package p\n\nfunc f() {\n} // tail\n"), &s) {
+		t.Fatal("updateGo failed to parse")
+	}
+	if s.CommentLines != 1 {
+		t.Fatalf("CommentLines = %d, want 1", s.CommentLines)
+	}
+	if s.CodeLines != 4 {
+		t.Fatalf("CodeLines = %d, want 4", s.CodeLines)
+	}
+}
+
+// A nested "/*" must push the comment depth so the matching inner "*/"
+// doesn't prematurely end the outer comment; only the final "*/" does,
+// on the third line here.
+func TestUpdateNestedBlockComment(t *testing.T) {
+	l := Language{"Nested", mExt(".nst"), Commenter{"\000", "/*", "*/", true}, noStrings}
+	var s Stats
+	l.Update([]byte("/* outer\n/* inner */\nstill open */\n"), &s)
+	if s.CommentLines != 2 {
+		t.Fatalf("CommentLines = %d, want 2", s.CommentLines)
+	}
+	if s.CodeLines != 3 {
+		t.Fatalf("CodeLines = %d, want 3", s.CodeLines)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+// StringDelim describes one kind of string literal a language supports:
+// where it starts and ends, and how escapes inside it work. Escape ==
+// "" means the delimiter can't be escaped out of at all (e.g. a Go
+// raw string), so any comment-looking bytes between Start and End are
+// still just string content.
+type StringDelim struct {
+	Start  string
+	End    string
+	Escape string
+}
+
+// Stringer lists the string forms a language recognizes, tried in
+// order, so longer delimiters (Python's `"""`) must come before
+// prefixes of themselves (`"`).
+type Stringer struct {
+	Strings []StringDelim
+}
+
+var (
+	noStrings = Stringer{}
+
+	// C/Go: `"..."` with backslash escapes, plus Go's back-tick raw
+	// strings, which have no escape mechanism at all.
+	cStrings = Stringer{[]StringDelim{
+		{Start: `"`, End: `"`, Escape: `\`},
+		{Start: "`", End: "`"},
+	}}
+
+	// Shell/Make/Perl: `"..."` supports backslash escapes, `'...'`
+	// does not.
+	//
+	// Out of scope: Perl's `q{...}`/`qq{...}` (and friends like
+	// `q(...)`/`q[...]`) pick their own bracket pair and nest, which
+	// StringDelim can't express - it only matches a fixed Start/End
+	// pair, not a balanced-bracket scan. Shell heredocs (`<<EOF`) are
+	// also unsupported: their terminator is a line-anchored marker
+	// chosen at the `<<`, not an inline delimiter, so they don't fit
+	// this Start/End model either. Both would need a dedicated matcher
+	// alongside Stringer rather than another StringDelim entry.
+	shStrings = Stringer{[]StringDelim{
+		{Start: `"`, End: `"`, Escape: `\`},
+		{Start: `'`, End: `'`},
+	}}
+
+	// Python: triple-quoted strings must be matched before the single
+	// character delimiters they start with.
+	pyStrings = Stringer{[]StringDelim{
+		{Start: `"""`, End: `"""`},
+		{Start: `'''`, End: `'''`},
+		{Start: `"`, End: `"`, Escape: `\`},
+		{Start: `'`, End: `'`, Escape: `\`},
+	}}
+)
+
+// hasPrefixAt reports whether p occurs in c starting at i. An empty or
+// sentinel ("\000") p never matches, mirroring how Commenter disables a
+// delimiter.
+func hasPrefixAt(c []byte, i int, p string) bool {
+	if p == "" || p == "\000" || i+len(p) > len(c) {
+		return false
+	}
+	return string(c[i:i+len(p)]) == p
+}
+
+// matchStart returns the first delimiter in the list that starts at i.
+func matchStart(delims []StringDelim, c []byte, i int) (StringDelim, bool) {
+	for _, d := range delims {
+		if hasPrefixAt(c, i, d.Start) {
+			return d, true
+		}
+	}
+	return StringDelim{}, false
+}
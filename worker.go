@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/fs"
+	"runtime"
+	"sync"
+)
+
+// numWorkers sizes the pool that processes files within a single
+// directory/archive concurrently, overridable with -j.
+var numWorkers = runtime.NumCPU()
+
+// workFiles runs handleFile for each path in paths across a pool of
+// numWorkers goroutines and folds their per-file results into a single
+// langStats. Merging happens in this one goroutine as results arrive
+// on a channel, so the map itself never needs a lock.
+func workFiles(fsys fs.FS, paths []string) langStats {
+	out := langStats{}
+	if len(paths) == 0 {
+		return out
+	}
+
+	workers := numWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	jobs := make(chan string)
+	results := make(chan langStats)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				one := langStats{}
+				handleFile(fsys, p, one)
+				results <- one
+			}
+		}()
+	}
+	go func() {
+		for _, p := range paths {
+			jobs <- p
+		}
+		close(jobs)
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		for name, s := range r {
+			i, ok := out[name]
+			if !ok {
+				i = &Stats{}
+				out[name] = i
+			}
+			addStats(i, s)
+		}
+	}
+	return out
+}
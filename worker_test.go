@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// TestWorkFilesAggregatesAcrossWorkers exercises the one property the
+// worker pool must preserve regardless of how many goroutines process
+// the files concurrently: every file's contribution ends up folded
+// into the result exactly once.
+func TestWorkFilesAggregatesAcrossWorkers(t *testing.T) {
+	old := cacheDir
+	cacheDir = ""
+	defer func() { cacheDir = old }()
+
+	fsys := fstest.MapFS{
+		"a.go": &fstest.MapFile{Data: []byte("package a\n")},
+		"b.go": &fstest.MapFile{Data: []byte("package b\n\nfunc f() {}\n")},
+		"c.py": &fstest.MapFile{Data: []byte("x = 1\n")},
+	}
+	paths := []string{"a.go", "b.go", "c.py"}
+
+	out := workFiles(fsys, paths)
+
+	if got := out["Go"].FileCount; got != 2 {
+		t.Fatalf("Go FileCount = %d, want 2", got)
+	}
+	if got := out["Go"].CodeLines; got != 4 {
+		t.Fatalf("Go CodeLines = %d, want 4 (1 from a.go + 3 from b.go)", got)
+	}
+	if got := out["Python"].FileCount; got != 1 {
+		t.Fatalf("Python FileCount = %d, want 1", got)
+	}
+}
+
+func TestWorkFilesEmptyInput(t *testing.T) {
+	out := workFiles(fstest.MapFS{}, nil)
+	if len(out) != 0 {
+		t.Fatalf("workFiles(nil) = %+v, want empty", out)
+	}
+}
+
+// TestWorkFilesConcurrencyIndependence pins numWorkers down to 1 and
+// up past the file count, and checks both give the same totals as the
+// pool's default sizing - the aggregation must not depend on how many
+// goroutines raced to produce it.
+func TestWorkFilesConcurrencyIndependence(t *testing.T) {
+	old := cacheDir
+	cacheDir = ""
+	defer func() { cacheDir = old }()
+
+	fsys := fstest.MapFS{
+		"a.go": &fstest.MapFile{Data: []byte("package a\n")},
+		"b.go": &fstest.MapFile{Data: []byte("package b\n")},
+		"c.go": &fstest.MapFile{Data: []byte("package c\n")},
+	}
+	paths := []string{"a.go", "b.go", "c.go"}
+
+	oldWorkers := numWorkers
+	defer func() { numWorkers = oldWorkers }()
+
+	for _, w := range []int{1, 3, 8} {
+		numWorkers = w
+		out := workFiles(fsys, paths)
+		if got := out["Go"].FileCount; got != 3 {
+			t.Fatalf("numWorkers=%d: FileCount = %d, want 3", w, got)
+		}
+		if got := out["Go"].CodeLines; got != 3 {
+			t.Fatalf("numWorkers=%d: CodeLines = %d, want 3", w, got)
+		}
+	}
+}